@@ -0,0 +1,43 @@
+// Package api contains Grafana's HTTP API handlers.
+package api
+
+import (
+	"strconv"
+
+	"github.com/grafana/grafana/pkg/infra/audit"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/registry"
+)
+
+func init() {
+	registry.Register(&registry.Descriptor{
+		Name:         "AdminAuditAPI",
+		Instance:     &AdminAuditAPI{},
+		InitPriority: registry.Low,
+	})
+}
+
+// AdminAuditAPI exposes GET /api/admin/audit (registered under
+// middleware.ReqGrafanaAdmin, like the rest of /api/admin) so operators can
+// query the tamper-evident trail recorded by audit.Service.
+type AdminAuditAPI struct {
+	AuditService *audit.Service `inject:""`
+}
+
+// GetAuditLog handles GET /api/admin/audit?orgId=&userId=&type=&limit=,
+// returning the most recent matching events, newest first.
+func (a *AdminAuditAPI) GetAuditLog(c *models.ReqContext) {
+	query := audit.Query{Type: c.Query("type")}
+
+	if orgID, err := strconv.ParseInt(c.Query("orgId"), 10, 64); err == nil {
+		query.OrgId = orgID
+	}
+	if userID, err := strconv.ParseInt(c.Query("userId"), 10, 64); err == nil {
+		query.UserId = userID
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil {
+		query.Limit = limit
+	}
+
+	c.JSON(200, a.AuditService.Query(query))
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/netpolicy"
+)
+
+func init() {
+	registry.Register(&registry.Descriptor{
+		Name:         "AdminNetPolicyAPI",
+		Instance:     &AdminNetPolicyAPI{},
+		InitPriority: registry.Low,
+	})
+}
+
+// AdminNetPolicyAPI exposes CRUD over network policy rules under
+// /api/admin/network-policies (registered under middleware.ReqGrafanaAdmin,
+// like the rest of /api/admin). Every mutation reloads NetPolicyService so
+// enforcement of org, API-key and user rules picks up the change immediately
+// rather than at next restart. Rules stored with scope "global" are not
+// enforced; see netpolicy.Service.Reload.
+type AdminNetPolicyAPI struct {
+	NetPolicyService *netpolicy.Service `inject:""`
+}
+
+// GetNetworkPolicyRules handles GET /api/admin/network-policies, optionally
+// filtered by ?scope=.
+func (a *AdminNetPolicyAPI) GetNetworkPolicyRules(c *models.ReqContext) {
+	query := models.GetNetworkPolicyRulesQuery{Scope: c.Query("scope")}
+	if err := bus.Dispatch(&query); err != nil {
+		c.JsonApiErr(500, "Failed to list network policy rules", err)
+		return
+	}
+	c.JSON(200, query.Result)
+}
+
+// PostNetworkPolicyRule handles POST /api/admin/network-policies.
+func (a *AdminNetPolicyAPI) PostNetworkPolicyRule(c *models.ReqContext, cmd models.AddNetworkPolicyRuleCmd) {
+	if err := bus.Dispatch(&cmd); err != nil {
+		c.JsonApiErr(500, "Failed to add network policy rule", err)
+		return
+	}
+
+	if err := a.NetPolicyService.Reload(); err != nil {
+		c.Logger.Error("Failed to reload network policy rules after add", "error", err)
+	}
+
+	c.JSON(200, cmd.Result)
+}
+
+// DeleteNetworkPolicyRule handles DELETE /api/admin/network-policies/:id.
+func (a *AdminNetPolicyAPI) DeleteNetworkPolicyRule(c *models.ReqContext) {
+	cmd := models.DeleteNetworkPolicyRuleCmd{Id: c.ParamsInt64(":id")}
+	if err := bus.Dispatch(&cmd); err != nil {
+		c.JsonApiErr(500, "Failed to delete network policy rule", err)
+		return
+	}
+
+	if err := a.NetPolicyService.Reload(); err != nil {
+		c.Logger.Error("Failed to reload network policy rules after delete", "error", err)
+	}
+
+	c.JSON(200, map[string]string{"message": "Network policy rule deleted"})
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/grafana/grafana/pkg/infra/audit"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// cspReport mirrors the "csp-report" object browsers POST to a
+// Content-Security-Policy report-uri/report-to target.
+type cspReport struct {
+	Report struct {
+		DocumentURI       string `json:"document-uri"`
+		ViolatedDirective string `json:"violated-directive"`
+		BlockedURI        string `json:"blocked-uri"`
+	} `json:"csp-report"`
+}
+
+// PostCSPReport handles POST /api/csp-report, the report-uri target used
+// when [security.headers] report-only mode is enabled, recording each
+// violation through the audit subsystem rather than Grafana's regular
+// request log.
+func PostCSPReport(c *models.ReqContext) {
+	var report cspReport
+	if err := json.NewDecoder(c.Req.Body).Decode(&report); err != nil {
+		c.JsonApiErr(400, "Invalid CSP report", err)
+		return
+	}
+
+	audit.Record(audit.Event{
+		Type:      audit.CSPViolation,
+		RequestId: c.Req.Header.Get("X-Request-Id"),
+		RemoteIP:  c.Req.RemoteAddr,
+		UserAgent: c.Req.UserAgent(),
+		Target:    report.Report.ViolatedDirective,
+		Outcome:   report.Report.BlockedURI,
+		Message:   report.Report.DocumentURI,
+	})
+
+	c.JSON(204, nil)
+}
@@ -0,0 +1,234 @@
+// Package audit provides a structured, tamper-evident audit trail for
+// security-relevant events: sign-ins, API key expiry, auth-proxy IP
+// rejections, token rotation and quota enforcement. Events are recorded
+// through the package-level Record function, in the same spirit as
+// pkg/bus.Dispatch, so call sites that have no natural place to inject a
+// *Service (e.g. the bus handlers in pkg/services/sqlstore) can still emit
+// audit events. Backends are selected via [log.audit] in grafana.ini, in
+// the same style as Grafana's existing [log.*] modes.
+package audit
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// Event types recorded by ContextHandler, the auth package's
+// Authenticator implementations, the quota handlers, and the CSP report
+// endpoint.
+const (
+	LoginSuccess       = "login.success"
+	LoginFailure       = "login.failure"
+	ApiKeyExpired      = "apikey.expired"
+	AuthProxyIPBlocked = "authproxy.ip_blocked"
+	TokenRotated       = "token.rotated"
+	QuotaExceeded      = "quota.exceeded"
+	QuotaUpdated       = "quota.updated"
+	CSPViolation       = "security.csp_violation"
+)
+
+// Event is a single audit record. Message carries event-specific detail
+// (e.g. the target that was exceeded) that doesn't warrant its own field.
+type Event struct {
+	Type       string
+	Time       time.Time
+	RequestId  string
+	RemoteIP   string
+	UserAgent  string
+	OrgId      int64
+	UserId     int64
+	ApiKeyId   int64
+	AuthMethod string
+	Target     string
+	Outcome    string
+	Message    string
+}
+
+// Recorder writes an Event to a single backend (file, syslog, webhook,
+// ...). A backend should not block on conditions the caller can't act on;
+// Service logs backend errors rather than propagating them, so audit
+// logging never fails the request it's observing. Service itself fans out
+// to backends from a single background goroutine (see Record), so a slow
+// Recorder (e.g. webhookBackend's HTTP POST) adds latency to that goroutine,
+// not to the caller.
+type Recorder interface {
+	Record(Event) error
+}
+
+// eventQueueCapacity bounds how many events can be buffered waiting for the
+// background fan-out goroutine to catch up with a slow backend. Once full,
+// Record drops new events rather than blocking the caller.
+const eventQueueCapacity = 1000
+
+func init() {
+	registry.Register(&registry.Descriptor{
+		Name:         "AuditService",
+		Instance:     &Service{},
+		InitPriority: registry.High,
+	})
+}
+
+// Service fans an Event out to every backend configured under [log.audit]
+// and keeps a bounded in-memory buffer of recently recorded events,
+// backing the GET /api/admin/audit endpoint.
+type Service struct {
+	Cfg *setting.Cfg `inject:""`
+
+	log      log.Logger
+	backends []Recorder
+	queue    chan Event
+
+	mu     sync.Mutex
+	recent []Event
+	cap    int
+}
+
+// Init builds the backends configured under [log.audit] and installs this
+// Service as the target of the package-level Record function.
+func (s *Service) Init() error {
+	s.log = log.New("audit")
+
+	s.cap = s.Cfg.AuditRecentCapacity
+	if s.cap <= 0 {
+		s.cap = 1000
+	}
+
+	for _, mode := range s.Cfg.AuditModes {
+		mode = strings.TrimSpace(mode)
+		switch mode {
+		case "":
+			continue
+		case "file":
+			s.backends = append(s.backends, newFileBackend(s.Cfg.AuditFilePath))
+		case "syslog":
+			b, err := newSyslogBackend(s.Cfg.AuditSyslogNetwork, s.Cfg.AuditSyslogAddress, s.Cfg.AuditSyslogTag)
+			if err != nil {
+				s.log.Error("Failed to initialize syslog audit backend", "error", err)
+				continue
+			}
+			s.backends = append(s.backends, b)
+		case "webhook":
+			s.backends = append(s.backends, newWebhookBackend(s.Cfg.AuditWebhookURL))
+		default:
+			s.log.Warn("Ignoring unknown audit mode", "mode", mode)
+		}
+	}
+
+	if len(s.backends) > 0 {
+		s.queue = make(chan Event, eventQueueCapacity)
+		go s.fanOutLoop()
+	}
+
+	setDefault(s)
+	return nil
+}
+
+// fanOutLoop drains the event queue and writes each event to every
+// configured backend. It runs for the lifetime of the process on a single
+// goroutine, so backends are written to one event at a time, in order, off
+// of every request goroutine that calls Record.
+func (s *Service) fanOutLoop() {
+	for event := range s.queue {
+		for _, b := range s.backends {
+			if err := b.Record(event); err != nil {
+				s.log.Error("Failed to write audit event", "type", event.Type, "error", err)
+			}
+		}
+	}
+}
+
+// Record appends event to the in-memory recent buffer queried by Query, then
+// queues it for the backends. It never blocks on a backend: if the queue is
+// full (a backend has fallen behind), the event is dropped from that fan-out
+// and only a warning is logged, rather than adding backend latency - up to
+// webhookBackend's full 5s HTTP timeout - to the caller's request.
+func (s *Service) Record(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	s.mu.Lock()
+	s.recent = append(s.recent, event)
+	if len(s.recent) > s.cap {
+		s.recent = s.recent[len(s.recent)-s.cap:]
+	}
+	s.mu.Unlock()
+
+	if s.queue == nil {
+		return
+	}
+
+	select {
+	case s.queue <- event:
+	default:
+		s.log.Warn("Dropping audit event, backend queue is full", "type", event.Type)
+	}
+}
+
+// Query selects events to return for GET /api/admin/audit.
+type Query struct {
+	OrgId  int64
+	UserId int64
+	Type   string
+	Limit  int
+}
+
+// Query returns events from the in-memory recent buffer matching q, newest
+// first. It only covers events recorded since this process started;
+// durable, queryable history is left to the configured backends (e.g.
+// grepping the audit file).
+func (s *Service) Query(q Query) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit := q.Limit
+	if limit <= 0 || limit > len(s.recent) {
+		limit = len(s.recent)
+	}
+
+	matches := make([]Event, 0, limit)
+	for i := len(s.recent) - 1; i >= 0 && len(matches) < limit; i-- {
+		e := s.recent[i]
+		if q.OrgId != 0 && e.OrgId != q.OrgId {
+			continue
+		}
+		if q.UserId != 0 && e.UserId != q.UserId {
+			continue
+		}
+		if q.Type != "" && e.Type != q.Type {
+			continue
+		}
+		matches = append(matches, e)
+	}
+	return matches
+}
+
+var (
+	defaultMu sync.RWMutex
+	def       *Service
+)
+
+func setDefault(s *Service) {
+	defaultMu.Lock()
+	def = s
+	defaultMu.Unlock()
+}
+
+// Record is a package-level convenience wrapping the Service installed by
+// Init, so callers that don't hold a *Service (e.g. pkg/services/sqlstore's
+// bus handlers) can still emit audit events. It's a no-op before Init runs
+// or if audit logging was never wired up, e.g. in tests.
+func Record(event Event) {
+	defaultMu.RLock()
+	s := def
+	defaultMu.RUnlock()
+	if s == nil {
+		return
+	}
+	s.Record(event)
+}
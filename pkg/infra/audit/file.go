@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// fileBackend appends each Event as a JSON line to a file. It's the
+// default [log.audit] mode.
+type fileBackend struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newFileBackend(path string) *fileBackend {
+	return &fileBackend{path: path}
+}
+
+func (b *fileBackend) Record(event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f, err := os.OpenFile(b.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	_, err = f.Write(line)
+	return err
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package audit
+
+import (
+	"encoding/json"
+	"log/syslog"
+)
+
+// syslogBackend writes each Event as a JSON payload to syslog. Not
+// supported on Windows; see syslog_windows.go.
+type syslogBackend struct {
+	writer *syslog.Writer
+}
+
+func newSyslogBackend(network, address, tag string) (*syslogBackend, error) {
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogBackend{writer: w}, nil
+}
+
+func (b *syslogBackend) Record(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return b.writer.Info(string(line))
+}
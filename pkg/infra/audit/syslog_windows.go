@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package audit
+
+import "errors"
+
+// syslogBackend is unavailable on Windows; a [log.audit] mode = syslog
+// backend fails to initialize there and is skipped with an error logged,
+// mirroring pkg/infra/log's own syslog mode.
+type syslogBackend struct{}
+
+func newSyslogBackend(network, address, tag string) (*syslogBackend, error) {
+	return nil, errors.New("syslog audit backend is not supported on windows")
+}
+
+func (b *syslogBackend) Record(Event) error { return nil }
@@ -0,0 +1,38 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// webhookBackend POSTs each Event as JSON to a configured URL, for piping
+// audit events into an external SIEM.
+type webhookBackend struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookBackend(url string) *webhookBackend {
+	return &webhookBackend{url: url, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (b *webhookBackend) Record(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.client.Post(b.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("audit webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
 )
 
 var (
@@ -20,8 +21,49 @@ var (
 	ReqOrgAdmin   = RoleAuth(models.ROLE_ADMIN)
 )
 
+// oauth2BearerAuthMethod mirrors auth.OAuth2Bearer. It's duplicated as a
+// literal rather than imported because pkg/services/auth already imports
+// pkg/middleware (for WriteSessionCookie), and importing it back here would
+// create a cycle.
+const oauth2BearerAuthMethod = "oauth2_bearer"
+
+// requireGrantAllowed reports whether a request authenticated via authMethod
+// carrying grants should be let through a RequireGrant(requiredGrant) check.
+// Only OAuth2 bearer tokens are grant-scoped, so every other auth method is
+// let through untouched. A bearer token is NOT exempted just because it
+// carries no grants at all - some identity providers never populate the
+// Grafana-specific "grants" claim (see pkg/services/oauth2/introspect.go),
+// and treating that the same as "not a bearer request" would make every
+// RequireGrant check a no-op for such tokens. So bearer requests fail closed
+// unless requiredGrant is explicitly present.
+func requireGrantAllowed(authMethod string, grants map[string]string, requiredGrant string) bool {
+	if authMethod != oauth2BearerAuthMethod {
+		return true
+	}
+	_, ok := grants[requiredGrant]
+	return ok
+}
+
+// RequireGrant builds a middleware that rejects the request with 403 unless
+// the signed-in user authenticated with an OAuth2 bearer token carrying the
+// given grant (e.g. "dashboards:read"). Requests authenticated by any other
+// method are allowed through untouched, since grants only constrain what a
+// bearer token may do.
+func RequireGrant(grant string) macaron.Handler {
+	return func(c *macaron.Context, ctx *models.ReqContext) {
+		if !requireGrantAllowed(ctx.SignedInUser.AuthMethod, ctx.SignedInUser.Grants, grant) {
+			ctx.JsonApiErr(403, "Token missing required grant: "+grant, nil)
+		}
+	}
+}
+
 func AddDefaultResponseHeaders() macaron.Handler {
 	return func(ctx *macaron.Context) {
+		// Generated once per request so the header and any inline <script
+		// nonce="..."> tags rendered by the handler agree on the same value.
+		nonce := util.GetRandomString(32)
+		ctx.Data["CSPNonce"] = nonce
+
 		ctx.Resp.Before(func(w macaron.ResponseWriter) {
 			// if response has already been written, skip.
 			if w.Written() {
@@ -36,13 +78,26 @@ func AddDefaultResponseHeaders() macaron.Handler {
 				AddXFrameOptionsDenyHeader(w)
 			}
 
-			AddSecurityHeaders(w)
+			AddSecurityHeaders(w, ctx.Req.URL.Path, nonce)
 		})
 	}
 }
 
-// AddSecurityHeaders adds various HTTP(S) response headers that enable various security protections behaviors in the client's browser.
-func AddSecurityHeaders(w macaron.ResponseWriter) {
+// CSPNonce returns the per-request nonce stashed by AddDefaultResponseHeaders,
+// letting plugin panels (and Grafana's own templates) emit
+// <script nonce="..."> tags that satisfy the configured
+// Content-Security-Policy without relaxing 'unsafe-inline' for everyone.
+func CSPNonce(ctx *macaron.Context) string {
+	nonce, _ := ctx.Data["CSPNonce"].(string)
+	return nonce
+}
+
+// AddSecurityHeaders adds various HTTP(S) response headers that enable various
+// security protections behaviors in the client's browser, as configured under
+// [security.headers] in grafana.ini. path is used to pick a per-route CSP
+// override (see setting.SecurityHeadersCSPRouteOverrides); nonce is the
+// current request's CSPNonce.
+func AddSecurityHeaders(w macaron.ResponseWriter, path, nonce string) {
 	if (setting.Protocol == setting.HTTPSScheme || setting.Protocol == setting.HTTP2Scheme) &&
 		setting.StrictTransportSecurity {
 		strictHeaderValues := []string{fmt.Sprintf("max-age=%v", setting.StrictTransportSecurityMaxAge)}
@@ -62,6 +117,54 @@ func AddSecurityHeaders(w macaron.ResponseWriter) {
 	if setting.XSSProtectionHeader {
 		w.Header().Add("X-XSS-Protection", "1; mode=block")
 	}
+
+	if setting.SecurityHeadersReferrerPolicy != "" {
+		w.Header().Add("Referrer-Policy", setting.SecurityHeadersReferrerPolicy)
+	}
+
+	if setting.SecurityHeadersPermissionsPolicy != "" {
+		w.Header().Add("Permissions-Policy", setting.SecurityHeadersPermissionsPolicy)
+	}
+
+	if setting.SecurityHeadersCrossOriginOpenerPolicy != "" {
+		w.Header().Add("Cross-Origin-Opener-Policy", setting.SecurityHeadersCrossOriginOpenerPolicy)
+	}
+
+	if setting.SecurityHeadersCrossOriginResourcePolicy != "" {
+		w.Header().Add("Cross-Origin-Resource-Policy", setting.SecurityHeadersCrossOriginResourcePolicy)
+	}
+
+	if csp := cspForPath(path, nonce); csp != "" {
+		headerName := "Content-Security-Policy"
+		if setting.SecurityHeadersCSPReportOnly {
+			headerName = "Content-Security-Policy-Report-Only"
+		}
+		w.Header().Add(headerName, csp)
+	}
+}
+
+// cspForPath renders the configured CSP template for path, substituting
+// nonce for the "$NONCE" placeholder so inline scripts carrying the
+// matching nonce attribute still execute. Route overrides
+// (setting.SecurityHeadersCSPRouteOverrides) are matched by the longest
+// configured path prefix, e.g. a relaxed policy for
+// /api/datasources/proxy/ and a strict one for /login, falling back to
+// setting.SecurityHeadersCSPTemplate.
+func cspForPath(path, nonce string) string {
+	template := setting.SecurityHeadersCSPTemplate
+
+	best := ""
+	for prefix, override := range setting.SecurityHeadersCSPRouteOverrides {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(best) {
+			best = prefix
+			template = override
+		}
+	}
+
+	if template == "" {
+		return ""
+	}
+	return strings.ReplaceAll(template, "$NONCE", nonce)
 }
 
 func AddNoCacheHeaders(w macaron.ResponseWriter) {
@@ -0,0 +1,59 @@
+package middleware
+
+import "testing"
+
+func TestRequireGrantAllowed(t *testing.T) {
+	tests := []struct {
+		name          string
+		authMethod    string
+		grants        map[string]string
+		requiredGrant string
+		want          bool
+	}{
+		{
+			name:          "non-bearer method is never grant-scoped",
+			authMethod:    "session_cookie",
+			grants:        nil,
+			requiredGrant: "dashboards:read",
+			want:          true,
+		},
+		{
+			name:          "bearer token with nil grants fails closed",
+			authMethod:    oauth2BearerAuthMethod,
+			grants:        nil,
+			requiredGrant: "dashboards:read",
+			want:          false,
+		},
+		{
+			name:          "bearer token with empty grants map fails closed",
+			authMethod:    oauth2BearerAuthMethod,
+			grants:        map[string]string{},
+			requiredGrant: "dashboards:read",
+			want:          false,
+		},
+		{
+			name:          "bearer token missing the required grant is denied",
+			authMethod:    oauth2BearerAuthMethod,
+			grants:        map[string]string{"datasources:query": "true"},
+			requiredGrant: "dashboards:read",
+			want:          false,
+		},
+		{
+			name:          "bearer token carrying the required grant is allowed",
+			authMethod:    oauth2BearerAuthMethod,
+			grants:        map[string]string{"dashboards:read": "true"},
+			requiredGrant: "dashboards:read",
+			want:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := requireGrantAllowed(tt.authMethod, tt.grants, tt.requiredGrant)
+			if got != tt.want {
+				t.Errorf("requireGrantAllowed(%q, %v, %q) = %v, want %v",
+					tt.authMethod, tt.grants, tt.requiredGrant, got, tt.want)
+			}
+		})
+	}
+}
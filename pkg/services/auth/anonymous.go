@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// AnonymousAuth signs requests in as the configured anonymous org/role when
+// [auth.anonymous] is enabled. It's always last in the default chain, since
+// once enabled it resolves every request that reaches it; it reports
+// ErrNotApplicable only when the anonymous org can't be looked up.
+type AnonymousAuth struct {
+	Cfg *setting.Cfg
+}
+
+func (a *AnonymousAuth) Method() AuthMethod { return Anonymous }
+
+func (a *AnonymousAuth) TryAuthenticate(ctx *models.ReqContext) (*models.SignedInUser, error) {
+	if !a.Cfg.AnonymousEnabled {
+		return nil, ErrNotApplicable
+	}
+
+	orgQuery := models.GetOrgByNameQuery{Name: a.Cfg.AnonymousOrgName}
+	if err := bus.Dispatch(&orgQuery); err != nil {
+		log.Errorf(3, "Anonymous access organization error: '%s': %s", a.Cfg.AnonymousOrgName, err)
+		return nil, ErrNotApplicable
+	}
+
+	ctx.AllowAnonymous = true
+
+	return &models.SignedInUser{
+		IsAnonymous: true,
+		OrgRole:     models.RoleType(a.Cfg.AnonymousOrgRole),
+		OrgId:       orgQuery.Result.Id,
+		OrgName:     orgQuery.Result.Name,
+	}, nil
+}
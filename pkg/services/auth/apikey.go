@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/components/apikeygen"
+	"github.com/grafana/grafana/pkg/infra/audit"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/netpolicy"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+const errStringInvalidAPIKey = "Invalid API key"
+
+// getTime is overridden in tests so expiry checks don't depend on the clock.
+var getTime = time.Now
+
+// APIKeyAuth recognizes a Grafana API key, either as an HTTP Bearer token or
+// as the password of a Basic Auth header whose username is "api_key".
+type APIKeyAuth struct {
+	NetPolicyService *netpolicy.Service
+}
+
+func (a *APIKeyAuth) Method() AuthMethod { return APIKey }
+
+func (a *APIKeyAuth) TryAuthenticate(ctx *models.ReqContext) (*models.SignedInUser, error) {
+	header := ctx.Req.Header.Get("Authorization")
+	parts := strings.SplitN(header, " ", 2)
+	var keyString string
+	if len(parts) == 2 && parts[0] == "Bearer" {
+		keyString = parts[1]
+	} else {
+		username, password, err := util.DecodeBasicAuthHeader(header)
+		if err == nil && username == "api_key" {
+			keyString = password
+		}
+	}
+
+	if keyString == "" {
+		return nil, ErrNotApplicable
+	}
+
+	decoded, err := apikeygen.Decode(keyString)
+	if err != nil {
+		ctx.JsonApiErr(401, errStringInvalidAPIKey, err)
+		return nil, errRejectedf(errStringInvalidAPIKey)
+	}
+
+	keyQuery := models.GetApiKeyByNameQuery{KeyName: decoded.Name, OrgId: decoded.OrgId}
+	if err := bus.Dispatch(&keyQuery); err != nil {
+		ctx.JsonApiErr(401, errStringInvalidAPIKey, err)
+		return nil, errRejectedf(errStringInvalidAPIKey)
+	}
+	apikey := keyQuery.Result
+
+	isValid, err := apikeygen.IsValid(decoded, apikey.Key)
+	if err != nil {
+		ctx.JsonApiErr(500, "Validating API key failed", err)
+		return nil, errRejectedf("Validating API key failed")
+	}
+	if !isValid {
+		ctx.JsonApiErr(401, errStringInvalidAPIKey, err)
+		return nil, errRejectedf(errStringInvalidAPIKey)
+	}
+
+	if apikey.Expires != nil && *apikey.Expires <= getTime().Unix() {
+		ctx.JsonApiErr(401, "Expired API key", err)
+		audit.Record(audit.Event{
+			Type:      audit.ApiKeyExpired,
+			RequestId: ctx.Req.Header.Get("X-Request-Id"),
+			RemoteIP:  ctx.Req.RemoteAddr,
+			OrgId:     apikey.OrgId,
+			ApiKeyId:  apikey.Id,
+			Outcome:   "expired",
+		})
+		return nil, errRejectedf("Expired API key")
+	}
+
+	if a.NetPolicyService != nil {
+		forwardedFor := ctx.Req.Header.Get("X-Forwarded-For")
+		if result := a.NetPolicyService.EvaluateApiKey(ctx.Req.RemoteAddr, forwardedFor, apikey.Id); !result.Allowed {
+			ctx.Logger.Warn("Rejected API key from unpinned source IP", "apiKeyId", apikey.Id, "remoteAddr", ctx.Req.RemoteAddr, "reason", result.Reason)
+			ctx.JsonApiErr(403, "Forbidden", nil)
+			return nil, errRejectedf("Forbidden: " + result.Reason)
+		}
+	}
+
+	return &models.SignedInUser{
+		OrgRole:  apikey.Role,
+		ApiKeyId: apikey.Id,
+		OrgId:    apikey.OrgId,
+	}, nil
+}
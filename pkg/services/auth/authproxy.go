@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/grafana/grafana/pkg/infra/audit"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/remotecache"
+	authproxy "github.com/grafana/grafana/pkg/middleware/auth_proxy"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// AuthProxyAuth resolves the user from a trusted reverse proxy's identity
+// header (e.g. X-WEBAUTH-USER), as configured under [auth.proxy].
+type AuthProxyAuth struct {
+	RemoteCacheService *remotecache.RemoteCache
+	HeaderName         string
+}
+
+func (a *AuthProxyAuth) Method() AuthMethod { return AuthProxy }
+
+func (a *AuthProxyAuth) TryAuthenticate(ctx *models.ReqContext) (*models.SignedInUser, error) {
+	username := ctx.Req.Header.Get(a.HeaderName)
+	proxy := authproxy.New(&authproxy.Options{
+		Store: a.RemoteCacheService,
+		Ctx:   ctx,
+		OrgID: ctx.OrgId,
+	})
+
+	logger := log.New("auth.proxy")
+
+	if !proxy.IsEnabled() {
+		return nil, ErrNotApplicable
+	}
+	if !proxy.HasHeader() {
+		return nil, ErrNotApplicable
+	}
+
+	if result, err := proxy.IsAllowedIP(); !result {
+		logger.Error("Failed to check whitelisted IP addresses", "message", err.Error(), "error", err.DetailsError)
+		ctx.Handle(407, err.Error(), err.DetailsError)
+		audit.Record(audit.Event{
+			Type:      audit.AuthProxyIPBlocked,
+			RequestId: ctx.Req.Header.Get("X-Request-Id"),
+			RemoteIP:  ctx.Req.RemoteAddr,
+			OrgId:     ctx.OrgId,
+			Outcome:   err.Error(),
+		})
+		return nil, errRejectedf(err.Error())
+	}
+
+	id, authErr := logUserIn(proxy, username, logger, false)
+	if authErr != nil {
+		ctx.Handle(407, authErr.Error(), authErr.DetailsError)
+		return nil, errRejectedf(authErr.Error())
+	}
+
+	signedInUser, err := proxy.GetSignedUser(id)
+	if err != nil {
+		// The ID may come from a stale cache entry (e.g. the user was
+		// deleted via the API, which can't invalidate cache keys derived
+		// from header values). Retry once without the cache.
+		logger.Debug("Failed to get user info given ID, retrying without cache", "userID", id)
+		if err := proxy.RemoveUserFromCache(logger); err != nil {
+			if !errors.Is(err, remotecache.ErrCacheItemNotFound) {
+				logger.Error("Got unexpected error when removing user from auth cache", "error", err)
+			}
+		}
+		id, authErr = logUserIn(proxy, username, logger, true)
+		if authErr != nil {
+			ctx.Handle(407, authErr.Error(), authErr.DetailsError)
+			return nil, errRejectedf(authErr.Error())
+		}
+
+		signedInUser, err = proxy.GetSignedUser(id)
+		if err != nil {
+			ctx.Handle(407, err.Error(), nil)
+			return nil, errRejectedf(err.Error())
+		}
+	}
+
+	logger.Debug("Successfully got user info", "userID", signedInUser.UserId, "username", signedInUser.Login)
+
+	if err := proxy.Remember(id); err != nil {
+		logger.Error("Failed to store user in cache", "username", username, "message", err.Error(), "error", err.DetailsError)
+		ctx.Handle(500, err.Error(), err.DetailsError)
+		return nil, errRejectedf(err.Error())
+	}
+
+	return signedInUser, nil
+}
+
+func logUserIn(proxy *authproxy.AuthProxy, username string, logger log.Logger, ignoreCache bool) (int64, *authproxy.Error) {
+	logger.Debug("Trying to log user in", "username", username, "ignoreCache", ignoreCache)
+	id, err := proxy.Login(logger, ignoreCache)
+	if err != nil {
+		logger.Error("Failed to login", "username", username, "message", err.Error(), "error", err.DetailsError, "ignoreCache", ignoreCache)
+		return 0, err
+	}
+	return id, nil
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/login"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+const errStringInvalidUsernamePassword = "Invalid username or password"
+
+// BasicAuth authenticates a username/password pair from an HTTP Basic Auth
+// header against Grafana's own user database.
+type BasicAuth struct {
+	Cfg   *setting.Cfg
+	Users user.Repository
+}
+
+func (a *BasicAuth) Method() AuthMethod { return BasicAuthM }
+
+func (a *BasicAuth) TryAuthenticate(ctx *models.ReqContext) (*models.SignedInUser, error) {
+	if !a.Cfg.BasicAuthEnabled {
+		return nil, ErrNotApplicable
+	}
+
+	header := ctx.Req.Header.Get("Authorization")
+	if header == "" {
+		return nil, ErrNotApplicable
+	}
+
+	username, password, err := util.DecodeBasicAuthHeader(header)
+	if err != nil {
+		ctx.JsonApiErr(401, "Invalid Basic Auth Header", err)
+		return nil, errRejectedf("Invalid Basic Auth Header")
+	}
+
+	authQuery := models.LoginUserQuery{Username: username, Password: password}
+	if err := bus.Dispatch(&authQuery); err != nil {
+		ctx.Logger.Debug("Failed to authorize the user", "username", username, "err", err)
+		if err == models.ErrUserNotFound {
+			err = login.ErrInvalidCredentials
+		}
+		ctx.JsonApiErr(401, errStringInvalidUsernamePassword, err)
+		return nil, errRejectedf(errStringInvalidUsernamePassword)
+	}
+
+	signedInUser, err := a.Users.Get(authQuery.User.Id, ctx.OrgId)
+	if err != nil {
+		ctx.Logger.Error("Failed at user signed in", "id", authQuery.User.Id)
+		ctx.JsonApiErr(401, errStringInvalidUsernamePassword, err)
+		return nil, errRejectedf(errStringInvalidUsernamePassword)
+	}
+
+	return signedInUser, nil
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/oauth2"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+// OAuth2BearerAuth recognizes opaque OAuth2 access tokens and JWTs issued by
+// an external identity provider, validated via pkg/services/oauth2.
+type OAuth2BearerAuth struct {
+	OAuth2Service *oauth2.Service
+	Users         user.Repository
+}
+
+func (a *OAuth2BearerAuth) Method() AuthMethod { return OAuth2Bearer }
+
+func (a *OAuth2BearerAuth) TryAuthenticate(ctx *models.ReqContext) (*models.SignedInUser, error) {
+	if a.OAuth2Service == nil || !a.OAuth2Service.IsEnabled() {
+		return nil, ErrNotApplicable
+	}
+
+	header := ctx.Req.Header.Get("Authorization")
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, ErrNotApplicable
+	}
+	token := parts[1]
+
+	if !oauth2.LooksLikeBearerToken(token) {
+		return nil, ErrNotApplicable
+	}
+
+	info, err := a.OAuth2Service.ValidateToken(ctx.Req.Context(), token)
+	if err != nil {
+		ctx.JsonApiErr(401, "Invalid OAuth2 bearer token", err)
+		return nil, errRejectedf("Invalid OAuth2 bearer token")
+	}
+
+	// Resolve against ctx.OrgId, like every other Authenticator, so a
+	// caller can select a non-default org via X-Grafana-Org-Id.
+	signedInUser, err := a.Users.GetByLogin(info.Username, ctx.OrgId)
+	if err != nil {
+		ctx.Logger.Error("Failed to resolve user for OAuth2 bearer token", "subject", info.Subject, "error", err)
+		ctx.JsonApiErr(401, "Invalid OAuth2 bearer token", err)
+		return nil, errRejectedf("Invalid OAuth2 bearer token")
+	}
+
+	signedInUser.Grants = info.Grants
+	return signedInUser, nil
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/rendering"
+)
+
+// RenderKeyAuth recognizes the short-lived "renderKey" cookie that Grafana's
+// own image-rendering service sets when it calls back into itself.
+type RenderKeyAuth struct {
+	RenderService rendering.Service
+}
+
+func (a *RenderKeyAuth) Method() AuthMethod { return RenderKey }
+
+func (a *RenderKeyAuth) TryAuthenticate(ctx *models.ReqContext) (*models.SignedInUser, error) {
+	key := ctx.GetCookie("renderKey")
+	if key == "" {
+		return nil, ErrNotApplicable
+	}
+
+	renderUser, exists := a.RenderService.GetRenderUser(key)
+	if !exists {
+		ctx.JsonApiErr(401, "Invalid Render Key", nil)
+		return nil, errRejectedf("Invalid Render Key")
+	}
+
+	ctx.IsRenderCall = true
+	ctx.LastSeenAt = time.Now()
+
+	return &models.SignedInUser{
+		OrgId:   renderUser.OrgID,
+		UserId:  renderUser.UserID,
+		OrgRole: models.RoleType(renderUser.OrgRole),
+	}, nil
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/infra/audit"
+	"github.com/grafana/grafana/pkg/middleware"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+	"gopkg.in/macaron.v1"
+)
+
+// SessionCookieAuth resolves the user from Grafana's own login session
+// cookie, rotating the underlying token just before the response is
+// written.
+type SessionCookieAuth struct {
+	Cfg              *setting.Cfg
+	Users            user.Repository
+	AuthTokenService models.UserTokenService
+}
+
+func (a *SessionCookieAuth) Method() AuthMethod { return SessionCookie }
+
+func (a *SessionCookieAuth) TryAuthenticate(ctx *models.ReqContext) (*models.SignedInUser, error) {
+	if a.Cfg.LoginCookieName == "" {
+		return nil, ErrNotApplicable
+	}
+
+	rawToken := ctx.GetCookie(a.Cfg.LoginCookieName)
+	if rawToken == "" {
+		return nil, ErrNotApplicable
+	}
+
+	token, err := a.AuthTokenService.LookupToken(ctx.Req.Context(), rawToken)
+	if err != nil {
+		ctx.Logger.Error("Failed to look up user based on cookie", "error", err)
+		middleware.WriteSessionCookie(ctx, a.Cfg, "", -1)
+		return nil, ErrNotApplicable
+	}
+
+	signedInUser, err := a.Users.Get(token.UserId, ctx.OrgId)
+	if err != nil {
+		ctx.Logger.Error("Failed to get user with id", "userId", token.UserId, "error", err)
+		return nil, ErrNotApplicable
+	}
+
+	ctx.UserToken = token
+	ctx.Resp.Before(a.rotateEndOfRequestFunc(ctx, token))
+
+	return signedInUser, nil
+}
+
+func (a *SessionCookieAuth) rotateEndOfRequestFunc(ctx *models.ReqContext, token *models.UserToken) macaron.BeforeFunc {
+	return func(w macaron.ResponseWriter) {
+		if w.Written() {
+			return
+		}
+		if ctx.Context.Req.Context().Err() == context.Canceled {
+			return
+		}
+
+		rotated, err := a.AuthTokenService.TryRotateToken(ctx.Req.Context(), token, ctx.RemoteAddr(), ctx.Req.UserAgent())
+		if err != nil {
+			ctx.Logger.Error("Failed to rotate token", "error", err)
+			return
+		}
+
+		if rotated {
+			middleware.WriteSessionCookie(ctx, a.Cfg, token.UnhashedToken, a.Cfg.LoginMaxLifetime)
+			audit.Record(audit.Event{
+				Type:      audit.TokenRotated,
+				RemoteIP:  ctx.RemoteAddr(),
+				UserAgent: ctx.Req.UserAgent(),
+				OrgId:     ctx.OrgId,
+				UserId:    token.UserId,
+				Outcome:   "rotated",
+			})
+		}
+	}
+}
@@ -2,33 +2,39 @@
 package contexthandler
 
 import (
-	"context"
 	"errors"
 	"strconv"
-	"strings"
-	"time"
 
 	"github.com/grafana/grafana/pkg/bus"
-	"github.com/grafana/grafana/pkg/components/apikeygen"
+	"github.com/grafana/grafana/pkg/infra/audit"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/infra/remotecache"
-	"github.com/grafana/grafana/pkg/middleware"
-	authproxy "github.com/grafana/grafana/pkg/middleware/auth_proxy"
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/registry"
-	"github.com/grafana/grafana/pkg/services/login"
+	"github.com/grafana/grafana/pkg/services/auth"
+	"github.com/grafana/grafana/pkg/services/netpolicy"
+	"github.com/grafana/grafana/pkg/services/oauth2"
+	"github.com/grafana/grafana/pkg/services/quota"
 	"github.com/grafana/grafana/pkg/services/rendering"
+	"github.com/grafana/grafana/pkg/services/user"
 	"github.com/grafana/grafana/pkg/setting"
-	"github.com/grafana/grafana/pkg/util"
 	"gopkg.in/macaron.v1"
 )
 
-const (
-	errStringInvalidUsernamePassword = "Invalid username or password"
-	errStringInvalidAPIKey           = "Invalid API key"
-)
-
-var getTime = time.Now
+// defaultAuthMethodOrder is used whenever [auth] auth_method_order isn't
+// set in grafana.ini. It keeps the order the old hardcoded switch tested
+// authentication mechanisms in, with OAuth2Bearer and JWT, which the switch
+// didn't have, tried right after RenderKey and ahead of APIKey.
+var defaultAuthMethodOrder = []auth.AuthMethod{
+	auth.RenderKey,
+	auth.OAuth2Bearer,
+	auth.JWT,
+	auth.APIKey,
+	auth.BasicAuthM,
+	auth.AuthProxy,
+	auth.SessionCookie,
+	auth.Anonymous,
+}
 
 func init() {
 	registry.Register(&registry.Descriptor{
@@ -44,13 +50,63 @@ type ContextHandler struct {
 	AuthTokenService   models.UserTokenService  `inject:""`
 	RemoteCacheService *remotecache.RemoteCache `inject:""`
 	RenderService      rendering.Service        `inject:""`
+	OAuth2Service      *oauth2.Service          `inject:""`
+	QuotaService       *quota.QuotaService      `inject:""`
+	NetPolicyService   *netpolicy.Service       `inject:""`
+
+	authChain *auth.Chain
 }
 
-// Init initializes the service.
+// Init builds the ordered Authenticator chain from [auth] auth_method_order,
+// falling back to defaultAuthMethodOrder. Unknown method names are skipped
+// with a warning rather than failing startup.
 func (h *ContextHandler) Init() error {
+	users := user.NewBusRepository()
+
+	byMethod := map[auth.AuthMethod]auth.Authenticator{
+		auth.RenderKey:     &auth.RenderKeyAuth{RenderService: h.RenderService},
+		auth.OAuth2Bearer:  &auth.OAuth2BearerAuth{OAuth2Service: h.OAuth2Service, Users: users},
+		auth.JWT:           &auth.JWTAuth{Cfg: h.Cfg, Users: users},
+		auth.APIKey:        &auth.APIKeyAuth{NetPolicyService: h.NetPolicyService},
+		auth.BasicAuthM:    &auth.BasicAuth{Cfg: h.Cfg, Users: users},
+		auth.AuthProxy:     &auth.AuthProxyAuth{RemoteCacheService: h.RemoteCacheService, HeaderName: h.Cfg.AuthProxyHeaderName},
+		auth.SessionCookie: &auth.SessionCookieAuth{Cfg: h.Cfg, Users: users, AuthTokenService: h.AuthTokenService},
+		auth.Anonymous:     &auth.AnonymousAuth{Cfg: h.Cfg},
+	}
+
+	order := defaultAuthMethodOrder
+	if len(h.Cfg.AuthMethodOrder) > 0 {
+		order = make([]auth.AuthMethod, 0, len(h.Cfg.AuthMethodOrder))
+		for _, name := range h.Cfg.AuthMethodOrder {
+			order = append(order, auth.AuthMethod(name))
+		}
+	}
+
+	authenticators := make([]auth.Authenticator, 0, len(order))
+	for _, method := range order {
+		a, ok := byMethod[method]
+		if !ok {
+			log.New("context").Warn("Ignoring unknown auth method in auth_method_order", "method", method)
+			continue
+		}
+		authenticators = append(authenticators, a)
+	}
+
+	h.authChain = auth.NewChain(authenticators...)
 	return nil
 }
 
+// isNewAuthentication reports whether method represents a credential that's
+// (re)validated from scratch on every request, as opposed to one that just
+// resumes a session/token Grafana already issued. SessionCookie and APIKey
+// are the latter: TryAuthenticate looks up an existing UserToken/API key row
+// on every call, so logging login.success for them would turn one session
+// or key into one event per request for as long as it is used. No
+// login.success is recorded for requests authenticated by these methods.
+func isNewAuthentication(method auth.AuthMethod) bool {
+	return method != auth.SessionCookie && method != auth.APIKey
+}
+
 // Middleware provides a middleware to initialize the Macaron context.
 func (h *ContextHandler) Middleware(c *macaron.Context) {
 	ctx := &models.ReqContext{
@@ -63,352 +119,103 @@ func (h *ContextHandler) Middleware(c *macaron.Context) {
 	}
 
 	const headerName = "X-Grafana-Org-Id"
-	orgID := int64(0)
 	orgIDHeader := ctx.Req.Header.Get(headerName)
 	if orgIDHeader != "" {
 		id, err := strconv.ParseInt(orgIDHeader, 10, 64)
 		if err == nil {
-			orgID = id
+			ctx.OrgId = id
 		} else {
 			ctx.Logger.Debug("Received invalid header", "header", headerName, "value", orgIDHeader)
 		}
 	}
 
-	// the order in which these are tested are important
-	// look for api key in Authorization header first
-	// then init session and look for userId in session
-	// then look for api key in session (special case for render calls via api)
-	// then test if anonymous access is enabled
-	switch {
-	case h.initContextWithRenderAuth(ctx):
-	case h.initContextWithApiKey(ctx):
-	case h.initContextWithBasicAuth(ctx, orgID):
-	case h.initContextWithAuthProxy(ctx, orgID):
-	case h.initContextWithToken(ctx, orgID):
-	case h.initContextWithAnonymousUser(ctx):
-	}
-
-	ctx.Logger = log.New("context", "userId", ctx.UserId, "orgId", ctx.OrgId, "uname", ctx.Login)
-	ctx.Data["ctx"] = ctx
-
-	c.Map(ctx)
-
-	// update last seen every 5min
-	if ctx.ShouldUpdateLastSeenAt() {
-		ctx.Logger.Debug("Updating last user_seen_at", "user_id", ctx.UserId)
-		if err := bus.Dispatch(&models.UpdateUserLastSeenAtCommand{UserId: ctx.UserId}); err != nil {
-			ctx.Logger.Error("Failed to update last_seen_at", "error", err)
-		}
-	}
-}
-
-func (h *ContextHandler) initContextWithAnonymousUser(ctx *models.ReqContext) bool {
-	if !h.Cfg.AnonymousEnabled {
-		return false
-	}
-
-	orgQuery := models.GetOrgByNameQuery{Name: h.Cfg.AnonymousOrgName}
-	if err := bus.Dispatch(&orgQuery); err != nil {
-		log.Errorf(3, "Anonymous access organization error: '%s': %s", h.Cfg.AnonymousOrgName, err)
-		return false
-	}
-
-	ctx.IsSignedIn = false
-	ctx.AllowAnonymous = true
-	ctx.SignedInUser = &models.SignedInUser{IsAnonymous: true}
-	ctx.OrgRole = models.RoleType(h.Cfg.AnonymousOrgRole)
-	ctx.OrgId = orgQuery.Result.Id
-	ctx.OrgName = orgQuery.Result.Name
-	return true
-}
-
-func (h *ContextHandler) initContextWithApiKey(ctx *models.ReqContext) bool {
-	header := ctx.Req.Header.Get("Authorization")
-	parts := strings.SplitN(header, " ", 2)
-	var keyString string
-	if len(parts) == 2 && parts[0] == "Bearer" {
-		keyString = parts[1]
-	} else {
-		username, password, err := util.DecodeBasicAuthHeader(header)
-		if err == nil && username == "api_key" {
-			keyString = password
-		}
-	}
-
-	if keyString == "" {
-		return false
-	}
-
-	// base64 decode key
-	decoded, err := apikeygen.Decode(keyString)
-	if err != nil {
-		ctx.JsonApiErr(401, errStringInvalidAPIKey, err)
-		return true
-	}
-
-	// fetch key
-	keyQuery := models.GetApiKeyByNameQuery{KeyName: decoded.Name, OrgId: decoded.OrgId}
-	if err := bus.Dispatch(&keyQuery); err != nil {
-		ctx.JsonApiErr(401, errStringInvalidAPIKey, err)
-		return true
-	}
-
-	apikey := keyQuery.Result
-
-	// validate api key
-	isValid, err := apikeygen.IsValid(decoded, apikey.Key)
-	if err != nil {
-		ctx.JsonApiErr(500, "Validating API key failed", err)
-		return true
-	}
-	if !isValid {
-		ctx.JsonApiErr(401, errStringInvalidAPIKey, err)
-		return true
-	}
-
-	// check for expiration
-	if apikey.Expires != nil && *apikey.Expires <= getTime().Unix() {
-		ctx.JsonApiErr(401, "Expired API key", err)
-		return true
-	}
-
-	ctx.IsSignedIn = true
-	ctx.SignedInUser = &models.SignedInUser{}
-	ctx.OrgRole = apikey.Role
-	ctx.ApiKeyId = apikey.Id
-	ctx.OrgId = apikey.OrgId
-	return true
-}
-
-func (h *ContextHandler) initContextWithBasicAuth(ctx *models.ReqContext, orgID int64) bool {
-	if !h.Cfg.BasicAuthEnabled {
-		return false
-	}
-
-	header := ctx.Req.Header.Get("Authorization")
-	if header == "" {
-		return false
-	}
-
-	username, password, err := util.DecodeBasicAuthHeader(header)
-	if err != nil {
-		ctx.JsonApiErr(401, "Invalid Basic Auth Header", err)
-		return true
-	}
-
-	authQuery := models.LoginUserQuery{
-		Username: username,
-		Password: password,
-	}
-	if err := bus.Dispatch(&authQuery); err != nil {
-		ctx.Logger.Debug(
-			"Failed to authorize the user",
-			"username", username,
-			"err", err,
-		)
-
-		if err == models.ErrUserNotFound {
-			err = login.ErrInvalidCredentials
-		}
-		ctx.JsonApiErr(401, errStringInvalidUsernamePassword, err)
-		return true
-	}
-
-	user := authQuery.User
-
-	query := models.GetSignedInUserQuery{UserId: user.Id, OrgId: orgID}
-	if err := bus.Dispatch(&query); err != nil {
-		ctx.Logger.Error(
-			"Failed at user signed in",
-			"id", user.Id,
-			"org", orgID,
-		)
-		ctx.JsonApiErr(401, errStringInvalidUsernamePassword, err)
-		return true
-	}
-
-	ctx.SignedInUser = query.Result
-	ctx.IsSignedIn = true
-	return true
-}
-
-func (h *ContextHandler) initContextWithToken(ctx *models.ReqContext, orgID int64) bool {
-	if h.Cfg.LoginCookieName == "" {
-		return false
-	}
-
-	rawToken := ctx.GetCookie(h.Cfg.LoginCookieName)
-	if rawToken == "" {
-		return false
-	}
-
-	token, err := h.AuthTokenService.LookupToken(ctx.Req.Context(), rawToken)
-	if err != nil {
-		ctx.Logger.Error("Failed to look up user based on cookie", "error", err)
-		middleware.WriteSessionCookie(ctx, h.Cfg, "", -1)
-		return false
-	}
-
-	query := models.GetSignedInUserQuery{UserId: token.UserId, OrgId: orgID}
-	if err := bus.Dispatch(&query); err != nil {
-		ctx.Logger.Error("Failed to get user with id", "userId", token.UserId, "error", err)
-		return false
-	}
-
-	ctx.SignedInUser = query.Result
-	ctx.IsSignedIn = true
-	ctx.UserToken = token
-
-	// Rotate the token just before we write response headers to ensure there is no delay between
-	// the new token being generated and the client receiving it.
-	ctx.Resp.Before(h.rotateEndOfRequestFunc(ctx, h.AuthTokenService, token))
-
-	return true
-}
-
-func (h *ContextHandler) rotateEndOfRequestFunc(ctx *models.ReqContext, authTokenService models.UserTokenService,
-	token *models.UserToken) macaron.BeforeFunc {
-	return func(w macaron.ResponseWriter) {
-		// if response has already been written, skip.
-		if w.Written() {
-			return
-		}
-
-		// if the request is cancelled by the client we should not try
-		// to rotate the token since the client would not accept any result.
-		if ctx.Context.Req.Context().Err() == context.Canceled {
-			return
-		}
-
-		rotated, err := authTokenService.TryRotateToken(ctx.Req.Context(), token, ctx.RemoteAddr(), ctx.Req.UserAgent())
-		if err != nil {
-			ctx.Logger.Error("Failed to rotate token", "error", err)
+	if h.NetPolicyService != nil {
+		forwardedFor := ctx.Req.Header.Get("X-Forwarded-For")
+		if result := h.NetPolicyService.EvaluateGlobal(ctx.Req.RemoteAddr, forwardedFor); !result.Allowed {
+			ctx.Logger.Warn("Rejected request from banned network", "remoteAddr", ctx.Req.RemoteAddr, "reason", result.Reason)
+			ctx.JsonApiErr(403, "Forbidden", nil)
+			c.Map(ctx)
 			return
 		}
-
-		if rotated {
-			middleware.WriteSessionCookie(ctx, h.Cfg, token.UnhashedToken, h.Cfg.LoginMaxLifetime)
+		if ctx.OrgId != 0 {
+			if result := h.NetPolicyService.EvaluateOrg(ctx.Req.RemoteAddr, forwardedFor, ctx.OrgId); !result.Allowed {
+				ctx.Logger.Warn("Rejected request outside org allow list", "remoteAddr", ctx.Req.RemoteAddr, "orgId", ctx.OrgId, "reason", result.Reason)
+				ctx.JsonApiErr(403, "Forbidden", nil)
+				c.Map(ctx)
+				return
+			}
 		}
 	}
-}
-
-func (h *ContextHandler) initContextWithRenderAuth(ctx *models.ReqContext) bool {
-	key := ctx.GetCookie("renderKey")
-	if key == "" {
-		return false
-	}
-
-	renderUser, exists := h.RenderService.GetRenderUser(key)
-	if !exists {
-		ctx.JsonApiErr(401, "Invalid Render Key", nil)
-		return true
-	}
 
-	ctx.IsSignedIn = true
-	ctx.SignedInUser = &models.SignedInUser{
-		OrgId:   renderUser.OrgID,
-		UserId:  renderUser.UserID,
-		OrgRole: models.RoleType(renderUser.OrgRole),
-	}
-	ctx.IsRenderCall = true
-	ctx.LastSeenAt = time.Now()
-	return true
-}
-
-func logUserIn(auth *authproxy.AuthProxy, username string, logger log.Logger, ignoreCache bool) (int64, *authproxy.Error) {
-	logger.Debug("Trying to log user in", "username", username, "ignoreCache", ignoreCache)
-	// Try to log in user via various providers
-	id, err := auth.Login(logger, ignoreCache)
+	// the order in which authenticators run is important and is configured
+	// via [auth] auth_method_order; see defaultAuthMethodOrder.
+	signedInUser, method, err := h.authChain.TryAuthenticate(ctx)
 	if err != nil {
-		logger.Error("Failed to login", "username", username, "message", err.Error(), "error", err.DetailsError,
-			"ignoreCache", ignoreCache)
-		return 0, err
-	}
-	return id, nil
-}
-
-func (h *ContextHandler) initContextWithAuthProxy(ctx *models.ReqContext, orgID int64) bool {
-	username := ctx.Req.Header.Get(h.Cfg.AuthProxyHeaderName)
-	auth := authproxy.New(&authproxy.Options{
-		Store: h.RemoteCacheService,
-		Ctx:   ctx,
-		OrgID: orgID,
-	})
-
-	logger := log.New("auth.proxy")
-
-	// Bail if auth proxy is not enabled
-	if !auth.IsEnabled() {
-		return false
-	}
-
-	// If there is no header - we can't move forward
-	if !auth.HasHeader() {
-		return false
-	}
-
-	// Check if allowed to continue with this IP
-	if result, err := auth.IsAllowedIP(); !result {
-		logger.Error(
-			"Failed to check whitelisted IP addresses",
-			"message", err.Error(),
-			"error", err.DetailsError,
-		)
-		ctx.Handle(407, err.Error(), err.DetailsError)
-		return true
+		ctx.Logger.Debug("Authentication failed", "method", method, "error", err)
+		audit.Record(audit.Event{
+			Type:       audit.LoginFailure,
+			RequestId:  ctx.Req.Header.Get("X-Request-Id"),
+			RemoteIP:   ctx.Req.RemoteAddr,
+			UserAgent:  ctx.Req.UserAgent(),
+			OrgId:      ctx.OrgId,
+			AuthMethod: string(method),
+			Outcome:    err.Error(),
+		})
+	} else if signedInUser != nil {
+		ctx.SignedInUser = signedInUser
+		if method != auth.Anonymous {
+			ctx.IsSignedIn = true
+			if isNewAuthentication(method) {
+				audit.Record(audit.Event{
+					Type:       audit.LoginSuccess,
+					RequestId:  ctx.Req.Header.Get("X-Request-Id"),
+					RemoteIP:   ctx.Req.RemoteAddr,
+					UserAgent:  ctx.Req.UserAgent(),
+					OrgId:      signedInUser.OrgId,
+					UserId:     signedInUser.UserId,
+					ApiKeyId:   signedInUser.ApiKeyId,
+					AuthMethod: string(method),
+					Outcome:    "ok",
+				})
+			}
+		}
 	}
 
-	id, err := logUserIn(auth, username, logger, false)
-	if err != nil {
-		ctx.Handle(407, err.Error(), err.DetailsError)
-		return true
-	}
+	ctx.Logger = log.New("context", "userId", ctx.UserId, "orgId", ctx.OrgId, "uname", ctx.Login, "authMethod", method)
+	ctx.Data["ctx"] = ctx
 
-	logger.Debug("Got user ID, getting full user info", "userID", id)
+	c.Map(ctx)
 
-	user, err := auth.GetSignedUser(id)
-	if err != nil {
-		// The reason we couldn't find the user corresponding to the ID might be that the ID was found from a stale
-		// cache entry. For example, if a user is deleted via the API, corresponding cache entries aren't invalidated
-		// because cache keys are computed from request header values and not just the user ID. Meaning that
-		// we can't easily derive cache keys to invalidate when deleting a user. To work around this, we try to
-		// log the user in again without the cache.
-		logger.Debug("Failed to get user info given ID, retrying without cache", "userID", id)
-		if err := auth.RemoveUserFromCache(logger); err != nil {
-			if !errors.Is(err, remotecache.ErrCacheItemNotFound) {
-				logger.Error("Got unexpected error when removing user from auth cache", "error", err)
+	if h.QuotaService != nil && ctx.IsSignedIn {
+		scope := quota.Scope{OrgId: ctx.OrgId, UserId: ctx.UserId, ApiKeyId: ctx.ApiKeyId}
+		if err := h.QuotaService.CheckAndIncrement(ctx.Req.Context(), quota.TargetAPIRequests, scope); err != nil {
+			var exceeded *quota.ErrQuotaExceeded
+			if errors.As(err, &exceeded) {
+				if exceeded.RetryAfter > 0 {
+					ctx.Resp.Header().Set("Retry-After", strconv.Itoa(int(exceeded.RetryAfter.Seconds())))
+				}
+				ctx.JsonApiErr(429, "API rate limit exceeded", err)
+				audit.Record(audit.Event{
+					Type:      audit.QuotaExceeded,
+					RequestId: ctx.Req.Header.Get("X-Request-Id"),
+					RemoteIP:  ctx.Req.RemoteAddr,
+					OrgId:     ctx.OrgId,
+					UserId:    ctx.UserId,
+					ApiKeyId:  ctx.ApiKeyId,
+					Target:    quota.TargetAPIRequests,
+					Outcome:   "exceeded",
+				})
+			} else {
+				ctx.Logger.Error("Failed to check quota", "target", quota.TargetAPIRequests, "error", err)
 			}
 		}
-		id, err = logUserIn(auth, username, logger, true)
-		if err != nil {
-			ctx.Handle(407, err.Error(), err.DetailsError)
-			return true
-		}
-
-		user, err = auth.GetSignedUser(id)
-		if err != nil {
-			ctx.Handle(407, err.Error(), err.DetailsError)
-			return true
-		}
 	}
 
-	logger.Debug("Successfully got user info", "userID", user.UserId, "username", user.Login)
-
-	// Add user info to context
-	ctx.SignedInUser = user
-	ctx.IsSignedIn = true
-
-	// Remember user data in cache
-	if err := auth.Remember(id); err != nil {
-		logger.Error(
-			"Failed to store user in cache",
-			"username", username,
-			"message", err.Error(),
-			"error", err.DetailsError,
-		)
-		ctx.Handle(500, err.Error(), err.DetailsError)
-		return true
+	// update last seen every 5min
+	if ctx.ShouldUpdateLastSeenAt() {
+		ctx.Logger.Debug("Updating last user_seen_at", "user_id", ctx.UserId)
+		if err := bus.Dispatch(&models.UpdateUserLastSeenAtCommand{UserId: ctx.UserId}); err != nil {
+			ctx.Logger.Error("Failed to update last_seen_at", "error", err)
+		}
 	}
-
-	return true
 }
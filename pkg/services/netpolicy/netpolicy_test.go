@@ -0,0 +1,98 @@
+package netpolicy
+
+import (
+	"net"
+	"testing"
+)
+
+func mustCIDR(t *testing.T, cidr string) *net.IPNet {
+	t.Helper()
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatalf("invalid test CIDR %q: %v", cidr, err)
+	}
+	return n
+}
+
+func TestResolveClientIP(t *testing.T) {
+	s := &Service{trustedProxy: []*net.IPNet{mustCIDR(t, "10.0.0.0/8")}}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		want         string
+	}{
+		{
+			name:       "no forwarded-for falls back to remoteAddr",
+			remoteAddr: "203.0.113.5:443",
+			want:       "203.0.113.5",
+		},
+		{
+			name:         "single trusted proxy hands off to the forwarded client",
+			remoteAddr:   "10.0.0.1:443",
+			forwardedFor: "203.0.113.5",
+			want:         "203.0.113.5",
+		},
+		{
+			name:         "chain of trusted proxies walks to the real client",
+			remoteAddr:   "10.0.0.2:443",
+			forwardedFor: "203.0.113.5, 10.0.0.1",
+			want:         "203.0.113.5",
+		},
+		{
+			name:         "untrusted immediate peer stops the walk at itself",
+			remoteAddr:   "198.51.100.1:443",
+			forwardedFor: "203.0.113.5",
+			want:         "198.51.100.1",
+		},
+		{
+			name:         "walk stops at the first untrusted hop, ignoring anything further left",
+			remoteAddr:   "10.0.0.1:443",
+			forwardedFor: "203.0.113.5, 198.51.100.1",
+			want:         "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.resolveClientIP(tt.remoteAddr, tt.forwardedFor)
+			if got.String() != tt.want {
+				t.Errorf("resolveClientIP(%q, %q) = %s, want %s", tt.remoteAddr, tt.forwardedFor, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateGlobal(t *testing.T) {
+	s := &Service{staticGlobal: []*net.IPNet{mustCIDR(t, "198.51.100.0/24")}}
+
+	if res := s.EvaluateGlobal("198.51.100.7:1234", ""); res.Allowed {
+		t.Errorf("expected banned network to be denied, got allowed")
+	}
+
+	if res := s.EvaluateGlobal("203.0.113.7:1234", ""); !res.Allowed {
+		t.Errorf("expected non-banned network to be allowed, got denied: %s", res.Reason)
+	}
+}
+
+func TestEvaluateOrg(t *testing.T) {
+	s := &Service{
+		rulesByOrg: map[int64][]*Rule{
+			1: {{Net: mustCIDR(t, "203.0.113.0/24"), Action: ActionAllow}},
+		},
+	}
+
+	if res := s.EvaluateOrg("203.0.113.9:1234", "", 1); !res.Allowed {
+		t.Errorf("expected IP within org allow list to be allowed, got denied: %s", res.Reason)
+	}
+
+	if res := s.EvaluateOrg("198.51.100.9:1234", "", 1); res.Allowed {
+		t.Errorf("expected IP outside org allow list to be denied, got allowed")
+	}
+
+	// An org with no configured rules permits any source IP.
+	if res := s.EvaluateOrg("198.51.100.9:1234", "", 2); !res.Allowed {
+		t.Errorf("expected org with no rules to allow any source IP, got denied: %s", res.Reason)
+	}
+}
@@ -0,0 +1,163 @@
+// Package oauth2 lets Grafana act as an OAuth2 resource server, validating
+// opaque bearer tokens issued by an external identity provider via RFC 7662
+// token introspection or JWKS-based JWT verification.
+package oauth2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/apikeygen"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/remotecache"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// ErrInvalidToken is returned when a bearer token fails introspection or
+// signature verification.
+var ErrInvalidToken = errors.New("oauth2: invalid or expired bearer token")
+
+const cachePrefix = "oauth2-token-"
+
+func init() {
+	registry.Register(&registry.Descriptor{
+		Name:         "OAuth2Service",
+		Instance:     &Service{},
+		InitPriority: registry.High,
+	})
+	remotecache.Register(&TokenInfo{})
+}
+
+// TokenInfo is the resolved identity and grants for a validated bearer token.
+type TokenInfo struct {
+	Subject  string
+	Username string
+	Email    string
+	Grants   map[string]string
+	Expiry   time.Time
+}
+
+// Service validates OAuth2 bearer tokens against a configured issuer and
+// caches the result for the configured introspection TTL.
+type Service struct {
+	Cfg                *setting.Cfg             `inject:""`
+	RemoteCacheService *remotecache.RemoteCache `inject:""`
+
+	introspector introspector
+	jwksVerifier *jwksVerifier
+	log          log.Logger
+}
+
+// Init wires up the introspection client and JWKS verifier from
+// [auth.oauth2] settings.
+func (s *Service) Init() error {
+	s.log = log.New("oauth2")
+
+	if !s.Cfg.OAuth2ResourceServerEnabled {
+		return nil
+	}
+
+	s.introspector = newIntrospectionClient(s.Cfg.OAuth2IntrospectionURL, s.Cfg.OAuth2ClientID, s.Cfg.OAuth2ClientSecret)
+	s.jwksVerifier = newJWKSVerifier(s.Cfg.OAuth2JWKSURL)
+
+	return nil
+}
+
+// IsEnabled reports whether Grafana is configured to accept OAuth2 bearer
+// tokens in addition to its own API keys.
+func (s *Service) IsEnabled() bool {
+	return s.Cfg.OAuth2ResourceServerEnabled
+}
+
+// LooksLikeBearerToken reports whether token is shaped like an opaque OAuth2
+// access token or a JWT, as opposed to a Grafana API key (which is always
+// base64 of a fixed internal structure decodable by apikeygen.Decode).
+func LooksLikeBearerToken(token string) bool {
+	if _, err := apikeygen.Decode(token); err == nil {
+		return false
+	}
+	return opaqueTokenPattern.MatchString(token) || looksLikeJWT(token)
+}
+
+// ValidateToken resolves token to a TokenInfo, using cached introspection
+// results when available. The cache key is a SHA-256 hash of the token.
+// Cached results are not evicted when a token is revoked; an entry lives
+// until the cache TTL or the token's own expiry, whichever comes first.
+func (s *Service) ValidateToken(ctx context.Context, token string) (*TokenInfo, error) {
+	cacheKey := cachePrefix + hashToken(token)
+
+	if cached, err := s.RemoteCacheService.Get(cacheKey); err == nil {
+		if info, ok := cached.(*TokenInfo); ok && !tokenExpired(info) {
+			return info, nil
+		}
+		// Fall through and re-validate: either the cached result has
+		// outlived the token's own expiry, or cache TTL rounding left a
+		// stale entry behind. The re-validation below overwrites it.
+	}
+
+	var (
+		info *TokenInfo
+		err  error
+	)
+
+	switch {
+	case looksLikeJWT(token):
+		info, err = s.jwksVerifier.Verify(ctx, token)
+	default:
+		info, err = s.introspector.Introspect(ctx, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, ErrInvalidToken
+	}
+	if tokenExpired(info) {
+		return nil, ErrInvalidToken
+	}
+
+	ttl := time.Duration(s.Cfg.OAuth2IntrospectionCacheTTL) * time.Second
+	if ttl <= 0 {
+		ttl = 60 * time.Second
+	}
+	// Never cache a validation result past the token's own expiry - a cache
+	// TTL longer than the identity provider's access-token lifetime would
+	// otherwise keep a since-expired (or revoked) token authenticating
+	// successfully until the cache entry ages out on its own.
+	if !info.Expiry.IsZero() {
+		if remaining := time.Until(info.Expiry); remaining < ttl {
+			ttl = remaining
+		}
+	}
+	if err := s.RemoteCacheService.Set(cacheKey, info, ttl); err != nil {
+		s.log.Debug("Failed to cache oauth2 token introspection result", "error", err)
+	}
+
+	return info, nil
+}
+
+// tokenExpired reports whether info carries a known expiry that has already
+// passed. A zero Expiry means the issuer didn't report one and is treated as
+// non-expiring here - it's not this package's job to guess a lifetime.
+func tokenExpired(info *TokenInfo) bool {
+	return !info.Expiry.IsZero() && !info.Expiry.After(time.Now())
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HasGrant reports whether info carries the given grant, e.g.
+// "dashboards:read" or "datasources:query".
+func (info *TokenInfo) HasGrant(grant string) bool {
+	if info == nil || info.Grants == nil {
+		return false
+	}
+	_, ok := info.Grants[grant]
+	return ok
+}
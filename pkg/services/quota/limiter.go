@@ -0,0 +1,147 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/remotecache"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// Limiter implements a sliding-window-counter rate limit keyed by an opaque
+// string. Allow debits one unit from the window for key and reports whether
+// the caller is still within limit, plus how long to wait before retrying
+// when it isn't.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit int64, window time.Duration) (allowed bool, retryAfter time.Duration, err error)
+}
+
+func newLimiter(cfg setting.RateLimitSettings, cache *remotecache.RemoteCache) Limiter {
+	if cfg.Backend == "redis" {
+		return &cacheLimiter{cache: cache}
+	}
+	return newMemoryLimiter()
+}
+
+// windowIndex places now within a fixed sequence of `window`-sized buckets:
+// idx is the bucket number, fraction is how far into that bucket now falls
+// (0 at its start, approaching 1 at its end). Weighting the previous
+// bucket's count by 1-fraction and adding it to the current bucket's count
+// approximates a true sliding window without having to store a timestamped
+// log entry per request.
+func windowIndex(now time.Time, window time.Duration) (idx int64, fraction float64) {
+	n := now.UnixNano()
+	w := window.Nanoseconds()
+	idx = n / w
+	fraction = float64(n%w) / float64(w)
+	return idx, fraction
+}
+
+func retryAfterFor(now time.Time, idx int64, window time.Duration) time.Duration {
+	windowEnd := time.Unix(0, (idx+1)*window.Nanoseconds())
+	return windowEnd.Sub(now)
+}
+
+// cacheLimiter implements a sliding-window counter on top of
+// RemoteCacheService, so it works transparently with whichever backend
+// (redis, memcached, database) Grafana's cache is already configured with.
+// Unlike a fixed window, weighting the previous window's count by its
+// remaining overlap with the current one avoids the burst-at-the-boundary
+// problem where a client could get 2x limit by timing requests either side
+// of a window edge.
+type cacheLimiter struct {
+	cache *remotecache.RemoteCache
+}
+
+func (l *cacheLimiter) Allow(_ context.Context, key string, limit int64, window time.Duration) (bool, time.Duration, error) {
+	now := time.Now()
+	idx, fraction := windowIndex(now, window)
+
+	curKey := bucketCacheKey(key, idx)
+	prevKey := bucketCacheKey(key, idx-1)
+
+	// Incr is an atomic read-modify-write against the cache backend, unlike
+	// a Get followed by a Set, so concurrent requests for the same key
+	// can't both read the same count and both decide they're under limit.
+	curCount, err := l.cache.Incr(curKey, window*2)
+	if err != nil {
+		return false, 0, err
+	}
+
+	var prevCount int64
+	if v, err := l.cache.Get(prevKey); err == nil {
+		if n, ok := v.(int64); ok {
+			prevCount = n
+		}
+	}
+
+	estimate := float64(prevCount)*(1-fraction) + float64(curCount)
+	if estimate > float64(limit) {
+		return false, retryAfterFor(now, idx, window), nil
+	}
+	return true, 0, nil
+}
+
+func bucketCacheKey(key string, idx int64) string {
+	return fmt.Sprintf("%s:w%d", key, idx)
+}
+
+// memoryLimiter is an in-process sliding-window-counter limiter used when no
+// shared cache backend is configured, or for single-instance deployments
+// where the extra round-trip through RemoteCacheService isn't warranted.
+type memoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*slidingBuckets
+}
+
+// slidingBuckets holds the two buckets a sliding-window-counter estimate
+// needs for one key: the count so far in the current window, and the count
+// the previous window ended with.
+type slidingBuckets struct {
+	idx       int64
+	curCount  int64
+	prevCount int64
+}
+
+func newMemoryLimiter() *memoryLimiter {
+	return &memoryLimiter{buckets: map[string]*slidingBuckets{}}
+}
+
+func (l *memoryLimiter) Allow(_ context.Context, key string, limit int64, window time.Duration) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	idx, fraction := windowIndex(now, window)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &slidingBuckets{idx: idx}
+		l.buckets[key] = b
+	} else if idx != b.idx {
+		if idx == b.idx+1 {
+			b.prevCount = b.curCount
+		} else {
+			b.prevCount = 0
+		}
+		b.idx = idx
+		b.curCount = 0
+	}
+
+	// Increment before checking, mirroring cacheLimiter.Allow's atomic
+	// Incr-then-check: checking the pre-increment count here would let this
+	// request through on the count of the requests before it rather than
+	// including itself, admitting one extra request per window versus the
+	// cache backend for the same configured limit. Like cacheLimiter, a
+	// rejected request still counts against the window - it was still an
+	// attempt.
+	b.curCount++
+	estimate := float64(b.prevCount)*(1-fraction) + float64(b.curCount)
+	if estimate > float64(limit) {
+		return false, retryAfterFor(now, idx, window), nil
+	}
+
+	return true, 0, nil
+}
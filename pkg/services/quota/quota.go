@@ -0,0 +1,143 @@
+// Package quota extends Grafana's storage-count quotas (see
+// pkg/services/sqlstore/quota.go) with rate-based targets such as
+// api_requests, datasource_queries and alert_evaluations, checked and
+// debited on every authenticated request.
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/infra/audit"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/remotecache"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// Rate-based quota targets. Unlike the storage-count targets in
+// pkg/services/sqlstore/quota.go (dashboard, data_source, ...), these are
+// not counted from a DB table but debited from a rolling window on every
+// request.
+const (
+	TargetAPIRequests       = "api_requests"
+	TargetDatasourceQueries = "datasource_queries"
+	TargetAlertEvaluations  = "alert_evaluations"
+)
+
+// ErrQuotaExceeded is returned by CheckAndIncrement when scope has no
+// remaining quota for target. RetryAfter is set for rate-based targets and
+// should be surfaced as a Retry-After response header.
+type ErrQuotaExceeded struct {
+	Target     string
+	RetryAfter time.Duration
+}
+
+func (e *ErrQuotaExceeded) Error() string {
+	return fmt.Sprintf("quota exceeded for target %q", e.Target)
+}
+
+// Scope identifies who a rate-based quota check applies to. At least one of
+// OrgId, UserId or ApiKeyId should be set.
+type Scope struct {
+	OrgId    int64
+	UserId   int64
+	ApiKeyId int64
+}
+
+func (s Scope) cacheKey(target string) string {
+	return fmt.Sprintf("quota-%s-org%d-user%d-key%d", target, s.OrgId, s.UserId, s.ApiKeyId)
+}
+
+var rateTargets = map[string]bool{
+	TargetAPIRequests:       true,
+	TargetDatasourceQueries: true,
+	TargetAlertEvaluations:  true,
+}
+
+// IsRateTarget reports whether target is debited as a rolling-window rate
+// limit rather than counted from a storage table.
+func IsRateTarget(target string) bool {
+	return rateTargets[target]
+}
+
+func init() {
+	registry.Register(&registry.Descriptor{
+		Name:         "QuotaService",
+		Instance:     &QuotaService{},
+		InitPriority: registry.Low,
+	})
+}
+
+// QuotaService checks and debits both the existing storage-count quotas and
+// the newer rate-based targets.
+type QuotaService struct {
+	Cfg                *setting.Cfg             `inject:""`
+	RemoteCacheService *remotecache.RemoteCache `inject:""`
+
+	limiter Limiter
+	log     log.Logger
+}
+
+// Init selects the rate-limit backend configured under [quota.rate_limits]
+// in grafana.ini.
+func (qs *QuotaService) Init() error {
+	qs.log = log.New("quota")
+	qs.limiter = newLimiter(qs.Cfg.RateLimits, qs.RemoteCacheService)
+	return nil
+}
+
+// CheckAndIncrement verifies that scope still has quota remaining for target.
+// For rate-based targets it debits one unit from a sliding window maintained
+// by the configured Limiter. For storage-count targets it only checks the
+// org quota via GetOrgQuotaByTargetQuery, and only when scope.OrgId is set;
+// user and global quotas are not checked and nothing is debited.
+func (qs *QuotaService) CheckAndIncrement(ctx context.Context, target string, scope Scope) error {
+	if IsRateTarget(target) {
+		return qs.checkRateTarget(ctx, target, scope)
+	}
+	return qs.checkStorageTarget(target, scope)
+}
+
+func (qs *QuotaService) checkRateTarget(ctx context.Context, target string, scope Scope) error {
+	limit, window := qs.Cfg.RateLimits.LimitFor(target, scope.OrgId, scope.ApiKeyId)
+	if limit <= 0 {
+		return nil
+	}
+
+	allowed, retryAfter, err := qs.limiter.Allow(ctx, scope.cacheKey(target), limit, window)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &ErrQuotaExceeded{Target: target, RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+func (qs *QuotaService) checkStorageTarget(target string, scope Scope) error {
+	if scope.OrgId != 0 {
+		query := models.GetOrgQuotaByTargetQuery{Target: target, OrgId: scope.OrgId}
+		if err := bus.Dispatch(&query); err != nil {
+			if errors.Is(err, bus.ErrHandlerNotFound) {
+				return nil
+			}
+			return err
+		}
+		if query.Result.Limit >= 0 && query.Result.Used >= query.Result.Limit {
+			audit.Record(audit.Event{
+				Type:    audit.QuotaExceeded,
+				OrgId:   scope.OrgId,
+				UserId:  scope.UserId,
+				Target:  target,
+				Outcome: "exceeded",
+			})
+			return &ErrQuotaExceeded{Target: target}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package sqlstore
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func (ss *SqlStore) addNetworkPolicyHandlers() {
+	bus.AddHandler("sql", ss.GetNetworkPolicyRules)
+	bus.AddHandler("sql", ss.AddNetworkPolicyRule)
+	bus.AddHandler("sql", ss.DeleteNetworkPolicyRule)
+}
+
+func (ss *SqlStore) GetNetworkPolicyRules(query *models.GetNetworkPolicyRulesQuery) error {
+	rules := make([]*models.NetworkPolicyRuleDTO, 0)
+	sess := ss.engine.Table("network_policy_rule")
+	if query.Scope != "" {
+		sess = sess.Where("scope=?", query.Scope)
+	}
+	if err := sess.Find(&rules); err != nil {
+		return err
+	}
+	query.Result = rules
+	return nil
+}
+
+func (ss *SqlStore) AddNetworkPolicyRule(cmd *models.AddNetworkPolicyRuleCmd) error {
+	return ss.inTransaction(func(sess *DBSession) error {
+		rule := models.NetworkPolicyRuleDTO{
+			Scope:   cmd.Scope,
+			ScopeId: cmd.ScopeId,
+			Cidr:    cmd.Cidr,
+			Action:  cmd.Action,
+			Created: time.Now(),
+		}
+		if _, err := sess.Table("network_policy_rule").Insert(&rule); err != nil {
+			return err
+		}
+		cmd.Result = &rule
+		return nil
+	})
+}
+
+func (ss *SqlStore) DeleteNetworkPolicyRule(cmd *models.DeleteNetworkPolicyRuleCmd) error {
+	return ss.inTransaction(func(sess *DBSession) error {
+		_, err := sess.Table("network_policy_rule").ID(cmd.Id).Delete(&models.NetworkPolicyRuleDTO{})
+		return err
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/infra/audit"
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/setting"
 )
@@ -97,7 +98,7 @@ func (ss *SqlStore) GetOrgQuotas(query *models.GetOrgQuotasQuery) error {
 }
 
 func (ss *SqlStore) UpdateOrgQuota(cmd *models.UpdateOrgQuotaCmd) error {
-	return ss.inTransaction(func(sess *DBSession) error {
+	err := ss.inTransaction(func(sess *DBSession) error {
 		//Check if quota is already defined in the DB
 		quota := models.Quota{
 			Target: cmd.Target,
@@ -125,6 +126,15 @@ func (ss *SqlStore) UpdateOrgQuota(cmd *models.UpdateOrgQuotaCmd) error {
 
 		return nil
 	})
+	if err == nil {
+		audit.Record(audit.Event{
+			Type:    audit.QuotaUpdated,
+			OrgId:   cmd.OrgId,
+			Target:  cmd.Target,
+			Outcome: "ok",
+		})
+	}
+	return err
 }
 
 func (ss *SqlStore) GetUserQuotaByTarget(query *models.GetUserQuotaByTargetQuery) error {
@@ -200,7 +210,7 @@ func (ss *SqlStore) GetUserQuotas(query *models.GetUserQuotasQuery) error {
 }
 
 func (ss *SqlStore) UpdateUserQuota(cmd *models.UpdateUserQuotaCmd) error {
-	return ss.inTransaction(func(sess *DBSession) error {
+	err := ss.inTransaction(func(sess *DBSession) error {
 		//Check if quota is already defined in the DB
 		quota := models.Quota{
 			Target: cmd.Target,
@@ -228,6 +238,15 @@ func (ss *SqlStore) UpdateUserQuota(cmd *models.UpdateUserQuotaCmd) error {
 
 		return nil
 	})
+	if err == nil {
+		audit.Record(audit.Event{
+			Type:    audit.QuotaUpdated,
+			UserId:  cmd.UserId,
+			Target:  cmd.Target,
+			Outcome: "ok",
+		})
+	}
+	return err
 }
 
 func (ss *SqlStore) GetGlobalQuotaByTarget(query *models.GetGlobalQuotaByTargetQuery) error {